@@ -0,0 +1,173 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package multichannel
+
+import (
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/hyperledger/fabric/common/channelconfig"
+	"github.com/hyperledger/fabric/common/ledger/blockledger"
+	"github.com/hyperledger/fabric/internal/pkg/identity"
+	"github.com/hyperledger/fabric/orderer/common/blockcutter"
+	"github.com/hyperledger/fabric/orderer/consensus"
+	"github.com/hyperledger/fabric/protoutil"
+)
+
+// ledgerResources bundles a chain's channel configuration together with the ledger
+// backing it, so a ChainSupport can answer both "what is my config" and "what is on
+// my ledger" questions through a single value.
+type ledgerResources struct {
+	channelconfig.Resources
+	blockledger.ReadWriter
+}
+
+// ChainSupport provides a single point of access and control for a chain's resources:
+// its channel configuration, its ledger, and the consensus.Chain that orders its
+// transactions. It also implements consensus.ConsenterSupport, so consenters can use
+// it to cut and commit blocks without knowing about the Registrar.
+type ChainSupport struct {
+	*ledgerResources
+	consensus.Chain
+
+	cutter blockcutter.Receiver
+
+	lastConfigSeq uint64
+
+	registrar *Registrar
+}
+
+// newChainSupport wires a ChainSupport for ledgerResources, handing it off to whichever
+// consenter is registered for the channel's configured orderer type.
+func newChainSupport(
+	registrar *Registrar,
+	ledgerResources *ledgerResources,
+	consenters map[string]consensus.Consenter,
+	signer identity.SignerSerializer,
+	blockcutterMetrics *blockcutter.Metrics,
+	bccsp bccsp.BCCSP,
+) *ChainSupport {
+	cs := &ChainSupport{
+		ledgerResources: ledgerResources,
+		registrar:       registrar,
+		cutter:          blockcutter.NewReceiverImpl(ledgerResources.ConfigtxValidator().ChannelID(), ledgerResources, blockcutterMetrics),
+	}
+
+	lastBlock := blockledger.GetBlock(ledgerResources, ledgerResources.Height()-1)
+	if _, err := protoutil.GetLastConfigIndexFromBlock(lastBlock); err != nil {
+		logger.Panicf("[channel %s] Error extracting last config index: %s", cs.ChannelID(), err)
+	}
+	cs.lastConfigSeq = cs.Sequence()
+
+	oc, ok := ledgerResources.OrdererConfig()
+	if !ok {
+		logger.Panicf("[channel %s] Config does not contain orderer config", cs.ChannelID())
+	}
+
+	consenter, ok := consenters[oc.ConsensusType()]
+	if !ok {
+		logger.Panicf("[channel %s] Requested consensus type %s is not registered", cs.ChannelID(), oc.ConsensusType())
+	}
+
+	chain, err := consenter.HandleChain(cs, nil)
+	if err != nil {
+		logger.Panicf("[channel %s] Error creating consenter: %s", cs.ChannelID(), err)
+	}
+	cs.Chain = chain
+
+	return cs
+}
+
+// start launches the chain's consensus.Chain so it can begin ordering.
+func (cs *ChainSupport) start() {
+	cs.Chain.Start()
+}
+
+// Reader returns the underlying ledger's reader half.
+func (cs *ChainSupport) Reader() blockledger.Reader {
+	return cs.ledgerResources
+}
+
+// ChannelID returns the channel ID this chain is associated with.
+func (cs *ChainSupport) ChannelID() string {
+	return cs.ConfigtxValidator().ChannelID()
+}
+
+// Sequence returns the current config sequence number for the channel.
+func (cs *ChainSupport) Sequence() uint64 {
+	return cs.ConfigtxValidator().Sequence()
+}
+
+// BlockCutter returns the block cutting helper used to batch envelopes for this channel.
+func (cs *ChainSupport) BlockCutter() blockcutter.Receiver {
+	return cs.cutter
+}
+
+// SharedConfig returns the orderer config for the channel.
+func (cs *ChainSupport) SharedConfig() channelconfig.Orderer {
+	oc, _ := cs.OrdererConfig()
+	return oc
+}
+
+// ChannelConfig returns the channel-level config for the channel.
+func (cs *ChainSupport) ChannelConfig() channelconfig.Channel {
+	return cs.Resources.ChannelConfig()
+}
+
+// Block returns the block with the given number, or nil if it does not exist.
+func (cs *ChainSupport) Block(number uint64) *cb.Block {
+	return blockledger.GetBlock(cs.ledgerResources, number)
+}
+
+// CreateNextBlock returns a block that, if committed, would become the next block on
+// this chain, built from messages.
+func (cs *ChainSupport) CreateNextBlock(messages []*cb.Envelope) *cb.Block {
+	return blockledger.CreateNextBlock(cs.ledgerResources, messages)
+}
+
+// WriteBlock commits block to the channel's ledger. For the system channel, it also
+// detects channel creation transactions riding along in ORDERER_TRANSACTION envelopes
+// and brings the resulting channels up.
+func (cs *ChainSupport) WriteBlock(block *cb.Block, encodedMetadataValue []byte) {
+	if cs == cs.registrar.systemChannel {
+		for _, txBytes := range block.Data.Data {
+			env, err := protoutil.UnmarshalEnvelope(txBytes)
+			if err != nil {
+				continue
+			}
+			chdr, err := protoutil.ChannelHeader(env)
+			if err != nil || cb.HeaderType(chdr.Type) != cb.HeaderType_ORDERER_TRANSACTION {
+				continue
+			}
+			cs.registrar.createChannelFromOrdererTransaction(env)
+		}
+	}
+
+	cs.appendBlock(block, encodedMetadataValue)
+}
+
+// WriteConfigBlock commits block to the ledger and refreshes the channel's resources
+// from the config transaction it carries.
+func (cs *ChainSupport) WriteConfigBlock(block *cb.Block, encodedMetadataValue []byte) {
+	cs.appendBlock(block, encodedMetadataValue)
+
+	envelope := protoutil.ExtractEnvelopeOrPanic(block, 0)
+	bundle, err := channelconfig.NewBundleFromEnvelope(envelope, cs.registrar.bccsp)
+	if err != nil {
+		logger.Panicf("[channel %s] Could not apply committed config: %s", cs.ChannelID(), err)
+	}
+	cs.Resources = bundle
+	cs.lastConfigSeq = cs.Sequence()
+}
+
+func (cs *ChainSupport) appendBlock(block *cb.Block, encodedMetadataValue []byte) {
+	if encodedMetadataValue != nil {
+		block.Metadata.Metadata[cb.BlockMetadataIndex_ORDERER] = protoutil.MarshalOrPanic(&cb.Metadata{Value: encodedMetadataValue})
+	}
+	if err := cs.ledgerResources.Append(block); err != nil {
+		logger.Panicf("[channel %s] Could not append block: %s", cs.ChannelID(), err)
+	}
+}
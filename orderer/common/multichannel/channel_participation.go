@@ -0,0 +1,154 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package multichannel
+
+import (
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/common/channelconfig"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// ChannelInfo summarizes the state of a single channel known to the Registrar, as
+// reported by ListChannels.
+type ChannelInfo struct {
+	ChannelID string
+
+	// IsSystemChannel reports whether this channel is the privileged system channel.
+	// It is always false when the registrar is running in systemless mode.
+	IsSystemChannel bool
+}
+
+// JoinChannel constructs a new chain from configBlock -- a channel genesis or config
+// block -- persists it via the ledger factory, and makes it available through GetChain.
+// It is the systemless-mode counterpart to the system channel's CONFIG transaction
+// flow: callers that run without a system channel use JoinChannel to bring channels up
+// one at a time instead of routing creation through a privileged chain. It refuses to run
+// at all while a system channel is present, the mirror image of NewChannelConfig's
+// refusal to run without one, since the two creation paths are mutually exclusive.
+// configBlock's config is validated -- including the orderer config and consensus type
+// registration checks from checkResources -- before anything is written to the ledger,
+// so a rejected join never leaves behind a persisted block that would block a retry.
+func (r *Registrar) JoinChannel(configBlock *cb.Block) (*ChainSupport, error) {
+	if r.systemChannel != nil || !r.config.ChannelParticipation.Enabled {
+		return nil, errors.New("cannot join a channel while a system channel is present")
+	}
+
+	envelope, err := protoutil.ExtractEnvelope(configBlock, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "block does not carry a config transaction")
+	}
+	chdr, err := protoutil.ChannelHeader(envelope)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not determine channel ID")
+	}
+	chainID := chdr.ChannelId
+
+	if _, err := protoutil.GetLastConfigIndexFromBlock(configBlock); err != nil {
+		return nil, errors.Wrap(err, "block does not carry LAST_CONFIG metadata")
+	}
+
+	bundle, err := channelconfig.NewBundleFromEnvelope(envelope, r.bccsp)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid channel config")
+	}
+	if _, ok := bundle.ConsortiumsConfig(); ok {
+		return nil, errors.Errorf("channel %s carries a system channel config; JoinChannel only accepts application channels", chainID)
+	}
+	if err := checkResources(bundle); err != nil {
+		return nil, errors.Wrapf(err, "config for channel %s is not compatible with this binary", chainID)
+	}
+
+	oc, _ := bundle.OrdererConfig()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.chains[chainID]; ok {
+		return nil, errors.Errorf("channel %s already exists", chainID)
+	}
+	if _, ok := r.consenters[oc.ConsensusType()]; !ok {
+		return nil, errors.Errorf("channel %s requires consensus type %s, which is not registered", chainID, oc.ConsensusType())
+	}
+
+	rl, err := r.ledgerFactory.GetOrCreate(chainID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting ledger for channel %s", chainID)
+	}
+	if rl.Height() != 0 {
+		return nil, errors.Errorf("ledger for channel %s already has blocks", chainID)
+	}
+	if err := rl.Append(configBlock); err != nil {
+		return nil, errors.Wrapf(err, "appending genesis block for channel %s", chainID)
+	}
+
+	ledgerResources := &ledgerResources{Resources: bundle, ReadWriter: rl}
+	cs := newChainSupport(r, ledgerResources, r.consenters, r.signer, r.blockcutterMetrics, r.bccsp)
+	r.chains[chainID] = cs
+	cs.start()
+
+	logger.Infof("Joined channel %s", chainID)
+
+	return cs, nil
+}
+
+// chainRemover is implemented by blockledger.Factory implementations that can reclaim
+// a channel's storage. It is not yet part of the blockledger.Factory interface itself,
+// so RemoveChannel asserts for it at the call site and fails cleanly on factories (such
+// as the ones in this tree today) that don't implement it, rather than assuming every
+// factory supports deletion.
+type chainRemover interface {
+	Remove(chainID string) error
+}
+
+// RemoveChannel halts chainID's chain, drops it from the registrar, and deletes its
+// ledger. It reuses the same halt-then-replace semantics CreateChain already relies on
+// when swapping out an existing chain. The system channel, if one exists, cannot be
+// removed this way.
+func (r *Registrar) RemoveChannel(chainID string) error {
+	remover, ok := r.ledgerFactory.(chainRemover)
+	if !ok {
+		return errors.Errorf("ledger factory %T does not support removing channels", r.ledgerFactory)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	cs, ok := r.chains[chainID]
+	if !ok {
+		return errors.Errorf("channel %s does not exist", chainID)
+	}
+	if chainID == r.systemChannelID {
+		return errors.New("the system channel cannot be removed")
+	}
+
+	cs.Chain.Halt()
+
+	if err := remover.Remove(chainID); err != nil {
+		return errors.Wrapf(err, "removing ledger for channel %s", chainID)
+	}
+	delete(r.chains, chainID)
+
+	logger.Infof("Removed channel %s", chainID)
+
+	return nil
+}
+
+// ListChannels returns the set of channels currently known to the registrar.
+func (r *Registrar) ListChannels() []ChannelInfo {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	infos := make([]ChannelInfo, 0, len(r.chains))
+	for chainID := range r.chains {
+		infos = append(infos, ChannelInfo{
+			ChannelID:       chainID,
+			IsSystemChannel: chainID == r.systemChannelID,
+		})
+	}
+	return infos
+}
@@ -0,0 +1,210 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package multichannel
+
+import (
+	"testing"
+
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/common/ledger/blockledger"
+	"github.com/hyperledger/fabric/common/ledger/blockledger/ramledger"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/hyperledger/fabric/internal/configtxgen/configtxgentest"
+	"github.com/hyperledger/fabric/internal/configtxgen/encoder"
+	genesisconfig "github.com/hyperledger/fabric/internal/configtxgen/localconfig"
+	"github.com/hyperledger/fabric/orderer/common/localconfig"
+	"github.com/hyperledger/fabric/orderer/consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+// removableLedgerFactory wraps a blockledger.Factory with a Remove method, so tests can
+// exercise RemoveChannel's success path without depending on any in-tree Factory
+// implementation actually supporting deletion yet.
+type removableLedgerFactory struct {
+	blockledger.Factory
+	removed []string
+}
+
+func (f *removableLedgerFactory) Remove(chainID string) error {
+	f.removed = append(f.removed, chainID)
+	return nil
+}
+
+func systemlessConfig() localconfig.TopLevel {
+	return localconfig.TopLevel{
+		ChannelParticipation: localconfig.ChannelParticipation{Enabled: true},
+	}
+}
+
+// appChannelGenesisBlock builds an application channel genesis block from the solo
+// sample profile with its consortiums dropped, so the block is not a system channel's.
+func appChannelGenesisBlock(channelID string) *cb.Block {
+	conf := configtxgentest.Load(genesisconfig.SampleInsecureSoloProfile)
+	conf.Consortiums = nil
+	return encoder.New(conf).GenesisBlockForChannel(channelID)
+}
+
+func TestInitializeSystemless(t *testing.T) {
+	t.Run("No system channel, participation enabled", func(t *testing.T) {
+		lf := ramledger.New(10)
+
+		assert.NotPanics(t, func() {
+			NewRegistrar(systemlessConfig(), lf, mockCrypto(), &disabled.Provider{}).Initialize(map[string]consensus.Consenter{})
+		})
+	})
+
+	t.Run("No system channel, participation disabled", func(t *testing.T) {
+		lf := ramledger.New(10)
+
+		assert.Panics(t, func() {
+			NewRegistrar(localconfig.TopLevel{}, lf, mockCrypto(), &disabled.Provider{}).Initialize(map[string]consensus.Consenter{})
+		})
+	})
+}
+
+func TestJoinChannel(t *testing.T) {
+	confSys := configtxgentest.Load(genesisconfig.SampleInsecureSoloProfile)
+
+	newSystemlessManager := func() *Registrar {
+		lf := ramledger.New(10)
+		consenters := map[string]consensus.Consenter{confSys.Orderer.OrdererType: &mockConsenter{}}
+		manager := NewRegistrar(systemlessConfig(), lf, mockCrypto(), &disabled.Provider{})
+		manager.Initialize(consenters)
+		return manager
+	}
+
+	t.Run("Join succeeds", func(t *testing.T) {
+		manager := newSystemlessManager()
+		genesisBlock := appChannelGenesisBlock("app-channel")
+
+		cs, err := manager.JoinChannel(genesisBlock)
+		assert.NoError(t, err)
+		assert.NotNil(t, cs)
+		assert.NotNil(t, manager.GetChain("app-channel"))
+	})
+
+	t.Run("Join rejects a channel that already exists", func(t *testing.T) {
+		manager := newSystemlessManager()
+		genesisBlock := appChannelGenesisBlock("app-channel")
+
+		_, err := manager.JoinChannel(genesisBlock)
+		assert.NoError(t, err)
+
+		_, err = manager.JoinChannel(genesisBlock)
+		assert.Error(t, err)
+		assert.Regexp(t, "already exists", err.Error())
+	})
+
+	t.Run("Join rejects a system channel config", func(t *testing.T) {
+		manager := newSystemlessManager()
+		systemGenesisBlock := encoder.New(confSys).GenesisBlock()
+
+		_, err := manager.JoinChannel(systemGenesisBlock)
+		assert.Error(t, err)
+		assert.Regexp(t, "system channel config", err.Error())
+	})
+
+	t.Run("Join rejected while a system channel is present", func(t *testing.T) {
+		lf := ramledger.New(10)
+		rl, err := lf.GetOrCreate(genesisconfig.TestChannelID)
+		assert.NoError(t, err)
+		assert.NoError(t, rl.Append(encoder.New(confSys).GenesisBlock()))
+
+		consenters := map[string]consensus.Consenter{confSys.Orderer.OrdererType: &mockConsenter{}}
+		manager := NewRegistrar(localconfig.TopLevel{}, lf, mockCrypto(), &disabled.Provider{})
+		manager.Initialize(consenters)
+
+		genesisBlock := appChannelGenesisBlock("app-channel")
+
+		_, err = manager.JoinChannel(genesisBlock)
+		assert.Error(t, err)
+		assert.Regexp(t, "system channel is present", err.Error())
+		assert.Nil(t, manager.GetChain("app-channel"))
+	})
+
+	t.Run("Join rejected when a block requires an unregistered consensus type", func(t *testing.T) {
+		manager := NewRegistrar(systemlessConfig(), ramledger.New(10), mockCrypto(), &disabled.Provider{})
+		manager.Initialize(map[string]consensus.Consenter{})
+		genesisBlock := appChannelGenesisBlock("app-channel")
+
+		_, err := manager.JoinChannel(genesisBlock)
+		assert.Error(t, err)
+		assert.Regexp(t, "not registered", err.Error())
+		assert.Nil(t, manager.GetChain("app-channel"))
+
+		rl, err := manager.ledgerFactory.GetOrCreate("app-channel")
+		assert.NoError(t, err)
+		assert.Zero(t, rl.Height(), "a rejected join must not have persisted the genesis block")
+	})
+}
+
+func TestRemoveChannel(t *testing.T) {
+	confSys := configtxgentest.Load(genesisconfig.SampleInsecureSoloProfile)
+
+	t.Run("Remove succeeds against a factory that supports it", func(t *testing.T) {
+		lf := &removableLedgerFactory{Factory: ramledger.New(10)}
+		consenters := map[string]consensus.Consenter{confSys.Orderer.OrdererType: &mockConsenter{}}
+		manager := NewRegistrar(systemlessConfig(), lf, mockCrypto(), &disabled.Provider{})
+		manager.Initialize(consenters)
+
+		genesisBlock := appChannelGenesisBlock("app-channel")
+		_, err := manager.JoinChannel(genesisBlock)
+		assert.NoError(t, err)
+
+		assert.NoError(t, manager.RemoveChannel("app-channel"))
+		assert.Nil(t, manager.GetChain("app-channel"))
+		assert.Equal(t, []string{"app-channel"}, lf.removed)
+	})
+
+	t.Run("Remove fails against a factory that cannot reclaim storage", func(t *testing.T) {
+		lf := ramledger.New(10)
+		consenters := map[string]consensus.Consenter{confSys.Orderer.OrdererType: &mockConsenter{}}
+		manager := NewRegistrar(systemlessConfig(), lf, mockCrypto(), &disabled.Provider{})
+		manager.Initialize(consenters)
+
+		genesisBlock := appChannelGenesisBlock("app-channel")
+		_, err := manager.JoinChannel(genesisBlock)
+		assert.NoError(t, err)
+
+		err = manager.RemoveChannel("app-channel")
+		assert.Error(t, err)
+		assert.Regexp(t, "does not support removing channels", err.Error())
+	})
+
+	t.Run("Cannot remove the system channel", func(t *testing.T) {
+		genesisBlockSys := encoder.New(confSys).GenesisBlock()
+		lf := &removableLedgerFactory{Factory: ramledger.New(10)}
+		rl, err := lf.GetOrCreate(genesisconfig.TestChannelID)
+		assert.NoError(t, err)
+		assert.NoError(t, rl.Append(genesisBlockSys))
+
+		consenters := map[string]consensus.Consenter{confSys.Orderer.OrdererType: &mockConsenter{}}
+		manager := NewRegistrar(localconfig.TopLevel{}, lf, mockCrypto(), &disabled.Provider{})
+		manager.Initialize(consenters)
+
+		err = manager.RemoveChannel(manager.SystemChannelID())
+		assert.Error(t, err)
+		assert.Regexp(t, "system channel cannot be removed", err.Error())
+	})
+}
+
+func TestListChannels(t *testing.T) {
+	confSys := configtxgentest.Load(genesisconfig.SampleInsecureSoloProfile)
+	lf := ramledger.New(10)
+	consenters := map[string]consensus.Consenter{confSys.Orderer.OrdererType: &mockConsenter{}}
+	manager := NewRegistrar(systemlessConfig(), lf, mockCrypto(), &disabled.Provider{})
+	manager.Initialize(consenters)
+
+	assert.Empty(t, manager.ListChannels())
+
+	genesisBlock := appChannelGenesisBlock("app-channel")
+	_, err := manager.JoinChannel(genesisBlock)
+	assert.NoError(t, err)
+
+	infos := manager.ListChannels()
+	assert.Equal(t, []ChannelInfo{{ChannelID: "app-channel", IsSystemChannel: false}}, infos)
+}
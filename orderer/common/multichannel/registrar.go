@@ -0,0 +1,293 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package multichannel tracks the channel resources for the orderer. It initially
+// loads the set of existing channels and, for each of them, constructs a ChainSupport
+// capable of routing transactions for that channel to a consensus.Chain. It also
+// supports creating new channels through the system channel's configuration transaction.
+package multichannel
+
+import (
+	"sync"
+
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/hyperledger/fabric/common/channelconfig"
+	"github.com/hyperledger/fabric/common/configtx"
+	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/hyperledger/fabric/common/ledger/blockledger"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/hyperledger/fabric/internal/pkg/identity"
+	"github.com/hyperledger/fabric/orderer/common/blockcutter"
+	"github.com/hyperledger/fabric/orderer/common/localconfig"
+	"github.com/hyperledger/fabric/orderer/consensus"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	msgVersion = int32(0)
+	epoch      = 0
+)
+
+var logger = flogging.MustGetLogger("orderer.common.multichannel")
+
+// checkResources makes sure that the channel config is compatible with this binary
+// and logs sanity checks.
+func checkResources(res channelconfig.Resources) error {
+	channelconfig.LogSanityChecks(res)
+	oc, ok := res.OrdererConfig()
+	if !ok {
+		return errors.New("config does not contain orderer config")
+	}
+	if err := oc.Capabilities().Supported(); err != nil {
+		return errors.Wrapf(err, "config requires unsupported orderer capabilities:")
+	}
+	if err := res.ChannelConfig().Capabilities().Supported(); err != nil {
+		return errors.Wrapf(err, "config requires unsupported channel capabilities:")
+	}
+	return nil
+}
+
+// checkResourcesOrPanic invokes checkResources and panics if an error is returned.
+func checkResourcesOrPanic(res channelconfig.Resources) {
+	if err := checkResources(res); err != nil {
+		logger.Panicf("[channel %s] %s", res.ConfigtxValidator().ChannelID(), err)
+	}
+}
+
+// configTx pulls the latest config transaction out of a chain's ledger.
+func configTx(reader blockledger.Reader) *cb.Envelope {
+	lastBlock := blockledger.GetBlock(reader, reader.Height()-1)
+	index, err := protoutil.GetLastConfigIndexFromBlock(lastBlock)
+	if err != nil {
+		logger.Panicf("Chain did not have appropriately encoded last config in its latest block: %s", err)
+	}
+	configBlock := blockledger.GetBlock(reader, index)
+	if configBlock == nil {
+		logger.Panicf("Config block does not exist")
+	}
+	return protoutil.ExtractEnvelopeOrPanic(configBlock, 0)
+}
+
+// wrapConfigTx wraps a CONFIG envelope in an ORDERER_TRANSACTION envelope so it can
+// travel through the system channel alongside normal transactions.
+func wrapConfigTx(env *cb.Envelope) *cb.Envelope {
+	result, err := protoutil.CreateSignedEnvelope(cb.HeaderType_ORDERER_TRANSACTION, "", nil, env, msgVersion, epoch)
+	if err != nil {
+		logger.Panicf("Error wrapping config tx: %s", err)
+	}
+	return result
+}
+
+// Registrar serves as a point of access and control for the individual channel resources
+// managed by the orderer. It tracks the ChainSupport for every channel the orderer knows
+// about and mediates the creation of new ones.
+type Registrar struct {
+	config localconfig.TopLevel
+
+	lock   sync.RWMutex
+	chains map[string]*ChainSupport
+
+	consenters         map[string]consensus.Consenter
+	ledgerFactory      blockledger.Factory
+	signer             identity.SignerSerializer
+	blockcutterMetrics *blockcutter.Metrics
+	bccsp              bccsp.BCCSP
+
+	systemChannelID string
+	systemChannel   *ChainSupport
+}
+
+// NewRegistrar produces a registrar which does not yet have its chains initialized.
+// Call Initialize to complete construction.
+func NewRegistrar(config localconfig.TopLevel, ledgerFactory blockledger.Factory, signer identity.SignerSerializer, metricsProvider metrics.Provider) *Registrar {
+	return &Registrar{
+		config:             config,
+		chains:             make(map[string]*ChainSupport),
+		ledgerFactory:      ledgerFactory,
+		signer:             signer,
+		blockcutterMetrics: blockcutter.NewMetrics(metricsProvider),
+	}
+}
+
+// Initialize loads the existing ledgers, wires up a ChainSupport for each one, and
+// starts their consensus chains. Normally, precisely one of the ledgers must carry a
+// system channel's genesis block, and Initialize panics if none or more than one does.
+// If config.ChannelParticipation.Enabled is set, Initialize instead tolerates running
+// with no system channel at all, relying on JoinChannel to bring channels up one at a
+// time; a second system channel is still rejected in either mode.
+func (r *Registrar) Initialize(consenters map[string]consensus.Consenter) {
+	r.consenters = consenters
+
+	existingChainIDs, err := r.ledgerFactory.ChainIDs()
+	if err != nil {
+		logger.Panicf("Error listing existing channels: %s", err)
+	}
+
+	for _, chainID := range existingChainIDs {
+		rl, err := r.ledgerFactory.GetOrCreate(chainID)
+		if err != nil {
+			logger.Panicf("Ledger factory reported chain %s but could not retrieve it: %s", chainID, err)
+		}
+
+		ledgerResources := r.newLedgerResources(rl)
+		checkResourcesOrPanic(ledgerResources)
+
+		if _, ok := ledgerResources.ConsortiumsConfig(); ok {
+			if r.systemChannelID != "" {
+				logger.Panicf("There appear to be two system chains %s and %s", r.systemChannelID, chainID)
+			}
+			r.systemChannelID = chainID
+		}
+
+		cs := newChainSupport(r, ledgerResources, r.consenters, r.signer, r.blockcutterMetrics, r.bccsp)
+		r.chains[chainID] = cs
+	}
+
+	if r.systemChannelID == "" {
+		if !r.config.ChannelParticipation.Enabled {
+			logger.Panicf("No system chain found")
+		}
+		logger.Infof("No system chain found; starting in systemless mode with %d application channel(s)", len(r.chains))
+	} else {
+		r.systemChannel = r.chains[r.systemChannelID]
+	}
+
+	for _, cs := range r.chains {
+		cs.start()
+	}
+}
+
+// newLedgerResources builds the channelconfig.Resources for a chain from the last
+// config transaction recorded in its ledger.
+func (r *Registrar) newLedgerResources(rl blockledger.ReadWriter) *ledgerResources {
+	configEnvelope := configTx(rl)
+	bundle, err := channelconfig.NewBundleFromEnvelope(configEnvelope, r.bccsp)
+	if err != nil {
+		logger.Panicf("Error creating channel config bundle: %s", err)
+	}
+	return &ledgerResources{
+		Resources:  bundle,
+		ReadWriter: rl,
+	}
+}
+
+// SystemChannelID returns the channel ID of the system channel.
+func (r *Registrar) SystemChannelID() string {
+	return r.systemChannelID
+}
+
+// GetChain retrieves the chain support for a given chain if it exists.
+func (r *Registrar) GetChain(chainID string) *ChainSupport {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.chains[chainID]
+}
+
+// CreateChain makes the Registrar create a new chain for the given chain ID from the
+// ledger that is already present, replacing any chain that may already exist for it.
+func (r *Registrar) CreateChain(chainID string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if cs, ok := r.chains[chainID]; ok {
+		cs.Chain.Halt()
+	}
+
+	rl, err := r.ledgerFactory.GetOrCreate(chainID)
+	if err != nil {
+		logger.Panicf("Error getting ledger for %s: %s", chainID, err)
+	}
+
+	ledgerResources := r.newLedgerResources(rl)
+	cs := newChainSupport(r, ledgerResources, r.consenters, r.signer, r.blockcutterMetrics, r.bccsp)
+	r.chains[chainID] = cs
+	cs.start()
+}
+
+// NewChannelConfig builds the genesis channel config a brand-new channel would have if
+// envConfigUpdate -- a channel creation transaction -- were applied. The config is built
+// from the update's own contents alone; the system channel must exist, but its
+// consortium definitions are not consulted.
+func (r *Registrar) NewChannelConfig(envConfigUpdate *cb.Envelope) (channelconfig.Resources, error) {
+	if r.systemChannel == nil {
+		return nil, errors.New("system channel is not initialized")
+	}
+
+	configUpdateEnv, err := protoutil.EnvelopeToConfigUpdate(envConfigUpdate)
+	if err != nil {
+		return nil, errors.Wrap(err, "failing initial channel config creation because of config update envelope unmarshaling error")
+	}
+
+	initialGroup, err := configtx.NewChannelGroup(configUpdateEnv.ConfigUpdate)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create initial config group")
+	}
+
+	return channelconfig.NewBundle(configUpdateEnv.ConfigUpdate.ChannelId, &cb.Config{ChannelGroup: initialGroup}, r.bccsp)
+}
+
+// createChannelFromOrdererTransaction unwraps an ORDERER_TRANSACTION envelope carrying a
+// new channel's creation config, seeds that channel's ledger with the resulting genesis
+// block, and brings the new chain up via CreateChain.
+func (r *Registrar) createChannelFromOrdererTransaction(env *cb.Envelope) {
+	payload, err := protoutil.UnmarshalPayload(env.Payload)
+	if err != nil {
+		logger.Panicf("Orderer transaction did not carry a valid payload: %s", err)
+	}
+
+	configTx, err := protoutil.UnmarshalEnvelope(payload.Data)
+	if err != nil {
+		logger.Panicf("Orderer transaction did not carry a valid config envelope: %s", err)
+	}
+
+	chdr, err := protoutil.ChannelHeader(configTx)
+	if err != nil {
+		logger.Panicf("Could not determine channel ID for new channel: %s", err)
+	}
+
+	rl, err := r.ledgerFactory.GetOrCreate(chdr.ChannelId)
+	if err != nil {
+		logger.Panicf("Error getting ledger for new channel %s: %s", chdr.ChannelId, err)
+	}
+
+	genesisBlock := protoutil.NewBlock(0, nil)
+	genesisBlock.Data = &cb.BlockData{Data: [][]byte{protoutil.MarshalOrPanic(configTx)}}
+	genesisBlock.Header.DataHash = protoutil.BlockDataHash(genesisBlock.Data)
+	genesisBlock.Metadata.Metadata[cb.BlockMetadataIndex_LAST_CONFIG] = protoutil.MarshalOrPanic(&cb.Metadata{
+		Value: protoutil.MarshalOrPanic(&cb.LastConfig{Index: 0}),
+	})
+
+	if err := rl.Append(genesisBlock); err != nil {
+		logger.Panicf("Could not write genesis block for new channel %s: %s", chdr.ChannelId, err)
+	}
+
+	r.CreateChain(chdr.ChannelId)
+}
+
+// BroadcastChannelSupport returns the channel header, whether the message is a config
+// message, the chain support for the message's channel, and an error, if any. It is
+// used by the broadcast path and rejects CONFIG messages, which must instead be
+// delivered to their channel via that channel's config update flow.
+func (r *Registrar) BroadcastChannelSupport(msg *cb.Envelope) (*cb.ChannelHeader, bool, *ChainSupport, error) {
+	chdr, err := protoutil.ChannelHeader(msg)
+	if err != nil {
+		return nil, false, nil, errors.WithMessage(err, "could not determine channel ID")
+	}
+
+	cs := r.GetChain(chdr.ChannelId)
+	if cs == nil {
+		return nil, false, nil, errors.Errorf("channel %s not found", chdr.ChannelId)
+	}
+
+	isConfig := cb.HeaderType(chdr.Type) == cb.HeaderType_CONFIG
+	if isConfig {
+		return chdr, true, cs, errors.New("message is of type that cannot be processed directly")
+	}
+
+	return chdr, false, cs, nil
+}
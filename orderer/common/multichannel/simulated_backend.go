@@ -0,0 +1,253 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package multichannel
+
+import (
+	"sync"
+
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/hyperledger/fabric/bccsp/sw"
+	"github.com/hyperledger/fabric/common/ledger/blockledger/ramledger"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/hyperledger/fabric/internal/configtxgen/encoder"
+	genesisconfig "github.com/hyperledger/fabric/internal/configtxgen/localconfig"
+	"github.com/hyperledger/fabric/internal/pkg/identity"
+	"github.com/hyperledger/fabric/orderer/common/localconfig"
+	"github.com/hyperledger/fabric/orderer/consensus"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// Options configures a SimulatedRegistrar. SystemChannelProfile is the only required
+// field; everything else falls back to a sane default so callers can stand up a chain
+// with a one-liner and only override what their test actually cares about.
+type Options struct {
+	// BCCSP is the crypto service provider used to validate signatures and config
+	// updates. Defaults to a software provider backed by a throwaway keystore if nil.
+	BCCSP bccsp.BCCSP
+
+	// Consenter drives every chain's block cutting and commit behavior. Defaults to
+	// simulatedConsenter, which cuts and commits synchronously; override it to
+	// exercise different batching or asynchronous semantics in a caller's own tests.
+	Consenter consensus.Consenter
+
+	// MetricsProvider supplies the metrics instrumentation for the simulated chains.
+	// Defaults to a disabled provider if nil.
+	MetricsProvider metrics.Provider
+
+	// Signer signs and serializes the identity used for transactions this package
+	// constructs on the caller's behalf, such as channel creation transactions.
+	Signer identity.SignerSerializer
+
+	// SystemChannelProfile describes the genesis configuration for the system channel.
+	SystemChannelProfile *genesisconfig.Profile
+
+	// SystemChannelID is the channel ID to use for the system channel. Defaults to
+	// genesisconfig.TestChannelID if empty.
+	SystemChannelID string
+
+	// MaxLedgerSize bounds the in-memory RAM ledger backing every simulated chain.
+	// Defaults to a generous size suitable for tests if zero.
+	MaxLedgerSize int
+}
+
+// SimulatedRegistrar is a fully wired, in-memory orderer stack intended for embedding
+// in the tests of downstream chaincode and SDK developers. It is backed by RAM ledgers
+// and a deterministic, synchronous fake consensus.Consenter, so commits complete before
+// the call that issued them returns -- there is no need to poll or sleep waiting for a
+// block to land. It wraps a *Registrar the same way main.go does, without requiring
+// callers to import any of this package's unexported helpers.
+type SimulatedRegistrar struct {
+	*Registrar
+
+	signer identity.SignerSerializer
+}
+
+// NewSimulatedRegistrar brings up a Registrar backed by RAM ledgers, seeded with a
+// system channel genesis block derived from opts.SystemChannelProfile.
+func NewSimulatedRegistrar(opts Options) (*SimulatedRegistrar, error) {
+	if opts.SystemChannelProfile == nil {
+		return nil, errors.New("a system channel profile is required")
+	}
+
+	systemChannelID := opts.SystemChannelID
+	if systemChannelID == "" {
+		systemChannelID = genesisconfig.TestChannelID
+	}
+
+	maxLedgerSize := opts.MaxLedgerSize
+	if maxLedgerSize == 0 {
+		maxLedgerSize = 1000
+	}
+
+	metricsProvider := opts.MetricsProvider
+	if metricsProvider == nil {
+		metricsProvider = &disabled.Provider{}
+	}
+
+	cryptoProvider := opts.BCCSP
+	if cryptoProvider == nil {
+		var err error
+		cryptoProvider, err = sw.NewDefaultSecurityLevelWithKeystore(sw.NewDummyKeyStore())
+		if err != nil {
+			return nil, errors.Wrap(err, "creating default BCCSP")
+		}
+	}
+
+	consenter := opts.Consenter
+	if consenter == nil {
+		consenter = &simulatedConsenter{}
+	}
+
+	lf := ramledger.New(maxLedgerSize)
+	rl, err := lf.GetOrCreate(systemChannelID)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating system channel ledger")
+	}
+
+	genesisBlock := encoder.New(opts.SystemChannelProfile).GenesisBlockForChannel(systemChannelID)
+	if err := rl.Append(genesisBlock); err != nil {
+		return nil, errors.Wrap(err, "appending system channel genesis block")
+	}
+
+	consenters := map[string]consensus.Consenter{
+		opts.SystemChannelProfile.Orderer.OrdererType: consenter,
+	}
+
+	registrar := NewRegistrar(localconfig.TopLevel{}, lf, opts.Signer, metricsProvider)
+	registrar.bccsp = cryptoProvider
+	registrar.Initialize(consenters)
+
+	return &SimulatedRegistrar{
+		Registrar: registrar,
+		signer:    opts.Signer,
+	}, nil
+}
+
+// Commit orders envs onto chainID's chain. Because the simulated consenter commits
+// synchronously, every envelope has already been cut into a block by the time Commit
+// returns.
+func (s *SimulatedRegistrar) Commit(chainID string, envs ...*cb.Envelope) error {
+	cs := s.GetChain(chainID)
+	if cs == nil {
+		return errors.Errorf("no such channel: %s", chainID)
+	}
+
+	for _, env := range envs {
+		if err := cs.Order(env, 0); err != nil {
+			return errors.Wrapf(err, "ordering envelope on channel %s", chainID)
+		}
+	}
+	return nil
+}
+
+// CreateChannel builds a channel creation transaction from profile, submits it through
+// the system channel, and returns the resulting ChainSupport once it has come up. It
+// replaces the genesis-block/wrapConfigTx boilerplate that TestCreateChain's "New
+// chain" subtest has to repeat by hand: constructing the CONFIG_UPDATE, proposing it
+// against the new channel's template config, and signing the resulting CONFIG
+// envelope are all done here instead of at each call site.
+func (s *SimulatedRegistrar) CreateChannel(name string, profile *genesisconfig.Profile) (*ChainSupport, error) {
+	envConfigUpdate, err := encoder.MakeChannelCreationTransaction(name, s.signer, profile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "constructing channel creation transaction for %s", name)
+	}
+
+	res, err := s.NewChannelConfig(envConfigUpdate)
+	if err != nil {
+		return nil, errors.Wrapf(err, "constructing initial channel config for %s", name)
+	}
+
+	configEnv, err := res.ConfigtxValidator().ProposeConfigUpdate(envConfigUpdate)
+	if err != nil {
+		return nil, errors.Wrapf(err, "proposing initial channel config for %s", name)
+	}
+
+	ingressTx, err := protoutil.CreateSignedEnvelope(cb.HeaderType_CONFIG, name, s.signer, configEnv, msgVersion, epoch)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating config transaction for %s", name)
+	}
+
+	if err := s.AdvanceToConfig(ingressTx); err != nil {
+		return nil, errors.Wrapf(err, "submitting channel creation transaction for %s", name)
+	}
+
+	cs := s.GetChain(name)
+	if cs == nil {
+		return nil, errors.Errorf("channel %s was not created", name)
+	}
+	return cs, nil
+}
+
+// AdvanceToConfig commits configEnv -- a properly formed CONFIG envelope -- to the
+// system channel. Channel creation, and any later reconfiguration of the system channel
+// itself, both funnel through this single call.
+func (s *SimulatedRegistrar) AdvanceToConfig(configEnv *cb.Envelope) error {
+	system := s.GetChain(s.SystemChannelID())
+	if system == nil {
+		return errors.New("system channel is not initialized")
+	}
+	chdr, err := protoutil.ChannelHeader(configEnv)
+	if err != nil {
+		return errors.Wrap(err, "could not determine channel ID")
+	}
+	if chdr.ChannelId == s.SystemChannelID() {
+		return system.Configure(configEnv, 0)
+	}
+	return system.Configure(wrapConfigTx(configEnv), 0)
+}
+
+// simulatedConsenter hands every chain a simulatedChain, which cuts and commits blocks
+// synchronously on the calling goroutine. This keeps SimulatedRegistrar-backed tests
+// deterministic without needing a real consensus protocol.
+type simulatedConsenter struct{}
+
+func (c *simulatedConsenter) HandleChain(support consensus.ConsenterSupport, metadata *cb.Metadata) (consensus.Chain, error) {
+	return &simulatedChain{support: support}, nil
+}
+
+type simulatedChain struct {
+	support consensus.ConsenterSupport
+	mutex   sync.Mutex
+}
+
+func (c *simulatedChain) Order(env *cb.Envelope, configSeq uint64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	batches, _ := c.support.BlockCutter().Ordered(env)
+	if pending := c.support.BlockCutter().Cut(); len(pending) > 0 {
+		batches = append(batches, pending)
+	}
+	for _, batch := range batches {
+		c.support.WriteBlock(c.support.CreateNextBlock(batch), nil)
+	}
+	return nil
+}
+
+func (c *simulatedChain) Configure(config *cb.Envelope, configSeq uint64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if pending := c.support.BlockCutter().Cut(); len(pending) > 0 {
+		c.support.WriteBlock(c.support.CreateNextBlock(pending), nil)
+	}
+	block := c.support.CreateNextBlock([]*cb.Envelope{config})
+	if chdr, err := protoutil.ChannelHeader(config); err == nil && cb.HeaderType(chdr.Type) == cb.HeaderType_ORDERER_TRANSACTION {
+		c.support.WriteBlock(block, nil)
+		return nil
+	}
+	c.support.WriteConfigBlock(block, nil)
+	return nil
+}
+
+func (c *simulatedChain) WaitReady() error         { return nil }
+func (c *simulatedChain) Errored() <-chan struct{} { return nil }
+func (c *simulatedChain) Start()                   {}
+func (c *simulatedChain) Halt()                    {}
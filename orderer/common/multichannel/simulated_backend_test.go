@@ -0,0 +1,58 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package multichannel
+
+import (
+	"testing"
+
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	ab "github.com/hyperledger/fabric-protos-go/orderer"
+	"github.com/hyperledger/fabric/internal/configtxgen/configtxgentest"
+	genesisconfig "github.com/hyperledger/fabric/internal/configtxgen/localconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulatedRegistrarCommit(t *testing.T) {
+	confSys := configtxgentest.Load(genesisconfig.SampleInsecureSoloProfile)
+
+	sr, err := NewSimulatedRegistrar(Options{
+		SystemChannelProfile: confSys,
+		Signer:               mockCrypto(),
+	})
+	assert.NoError(t, err)
+
+	tx := makeNormalTx(sr.SystemChannelID(), 0)
+	assert.NoError(t, sr.Commit(sr.SystemChannelID(), tx))
+
+	it, _ := sr.GetChain(sr.SystemChannelID()).Reader().Iterator(&ab.SeekPosition{Type: &ab.SeekPosition_Specified{Specified: &ab.SeekSpecified{Number: 1}}})
+	defer it.Close()
+	block, status := it.Next()
+	assert.Equal(t, cb.Status_SUCCESS, status, "Could not retrieve the block Commit should have cut")
+	assert.Len(t, block.Data.Data, 1)
+}
+
+func TestSimulatedRegistrarCreateChannel(t *testing.T) {
+	confSys := configtxgentest.Load(genesisconfig.SampleInsecureSoloProfile)
+
+	sr, err := NewSimulatedRegistrar(Options{
+		SystemChannelProfile: confSys,
+		Signer:               mockCrypto(),
+	})
+	assert.NoError(t, err)
+
+	assert.Nil(t, sr.GetChain("test-new-chain"), "Should not have found a chain that was not yet created")
+
+	orglessChannelConf := configtxgentest.Load(genesisconfig.SampleSingleMSPChannelProfile)
+	orglessChannelConf.Application.Organizations = nil
+
+	cs, err := sr.CreateChannel("test-new-chain", orglessChannelConf)
+	assert.NoError(t, err, "CreateChannel should build, sign, and commit the channel creation transaction")
+	assert.NotNil(t, cs)
+	assert.Same(t, cs, sr.GetChain("test-new-chain"))
+
+	assert.NoError(t, sr.Commit("test-new-chain", makeNormalTx("test-new-chain", 0)))
+}
@@ -0,0 +1,103 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package multichannel
+
+import (
+	"fmt"
+
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/orderer/consensus"
+	"github.com/hyperledger/fabric/protoutil"
+)
+
+// mockConsenter hands every chain a mockChain, which queues envelopes onto an
+// unbuffered channel drained by the goroutine that Start launches. MESSAGE envelopes
+// are batched through the chain's block cutter; any other envelope first flushes the
+// pending batch and is then written as a block of its own.
+type mockConsenter struct{}
+
+func (mc *mockConsenter) HandleChain(support consensus.ConsenterSupport, metadata *cb.Metadata) (consensus.Chain, error) {
+	return &mockChain{
+		queue:   make(chan *cb.Envelope),
+		support: support,
+		done:    make(chan struct{}),
+	}, nil
+}
+
+type mockChain struct {
+	queue   chan *cb.Envelope
+	support consensus.ConsenterSupport
+	done    chan struct{}
+}
+
+func (mch *mockChain) Errored() <-chan struct{} {
+	return nil
+}
+
+func (mch *mockChain) Start() {
+	go func() {
+		defer close(mch.done)
+		for msg := range mch.queue {
+			chdr, err := protoutil.ChannelHeader(msg)
+			if err == nil && cb.HeaderType(chdr.Type) == cb.HeaderType_MESSAGE {
+				batches, _ := mch.support.BlockCutter().Ordered(msg)
+				for _, batch := range batches {
+					mch.support.WriteBlock(mch.support.CreateNextBlock(batch), nil)
+				}
+				continue
+			}
+			if batch := mch.support.BlockCutter().Cut(); len(batch) > 0 {
+				mch.support.WriteBlock(mch.support.CreateNextBlock(batch), nil)
+			}
+			mch.support.WriteBlock(mch.support.CreateNextBlock([]*cb.Envelope{msg}), nil)
+		}
+	}()
+}
+
+func (mch *mockChain) Halt() {
+	close(mch.queue)
+}
+
+func (mch *mockChain) WaitReady() error {
+	return nil
+}
+
+func (mch *mockChain) Order(env *cb.Envelope, configSeq uint64) error {
+	mch.queue <- env
+	return nil
+}
+
+func (mch *mockChain) Configure(config *cb.Envelope, configSeq uint64) error {
+	mch.queue <- config
+	return nil
+}
+
+// makeNormalTx constructs a deterministic MESSAGE envelope for channelID, tagged with
+// i so tests can tell otherwise-identical transactions apart.
+func makeNormalTx(channelID string, i int) *cb.Envelope {
+	return makeTx(channelID, i, cb.HeaderType_MESSAGE)
+}
+
+// makeConfigTx constructs a deterministic CONFIG envelope for channelID, tagged with i.
+func makeConfigTx(channelID string, i int) *cb.Envelope {
+	return makeTx(channelID, i, cb.HeaderType_CONFIG)
+}
+
+func makeTx(channelID string, i int, txType cb.HeaderType) *cb.Envelope {
+	payload := &cb.Payload{
+		Header: &cb.Header{
+			ChannelHeader: protoutil.MarshalOrPanic(&cb.ChannelHeader{
+				Type:      int32(txType),
+				ChannelId: channelID,
+			}),
+		},
+		Data: []byte(fmt.Sprintf("%d", i)),
+	}
+	return &cb.Envelope{
+		Payload: protoutil.MarshalOrPanic(payload),
+	}
+}